@@ -0,0 +1,193 @@
+// Copyright 2018 @boseji <salearj@hotmail.com> All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package graceful provides a zero-downtime restart and socket activation
+// layer on top of `net/http`.
+//
+// A `GracefulServer` listens on a `net.Listener` that can either be created
+// fresh, inherited from a parent process across a restart (SIGHUP), or
+// handed over by systemd via the sd_listen_fds socket activation protocol.
+//
+// On restart the current process forks/execs itself and passes the
+// listening socket to the child through `ExtraFiles` and the
+// `MSERVER_LISTENER_FDS` environment variable. `Restart` only starts the
+// child: it neither stops the parent's server nor waits for in-flight
+// requests, which `GracefulServer.Wait` tracks separately.
+package graceful
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// EnvListenerFDs is the environment variable a restarted child process
+// reads to find out how many listener file descriptors were passed down
+// by its parent via `ExtraFiles`.
+const EnvListenerFDs = "MSERVER_LISTENER_FDS"
+
+// EnvListenFDs and EnvListenPID are the standard systemd sd_listen_fds(3)
+// environment variables used for socket activation.
+//
+// See https://www.freedesktop.org/software/systemd/man/sd_listen_fds.html
+const (
+	EnvListenFDs = "LISTEN_FDS"
+	EnvListenPID = "LISTEN_PID"
+)
+
+// listenFDStart is the first file descriptor number used by both the
+// inherited-listener protocol and the systemd socket activation protocol.
+const listenFDStart = 3
+
+// GracefulServer wraps `http.Server` with the ability to hand its listening
+// socket to a freshly exec'd copy of the running binary, and to keep track
+// of in-flight connections so a shutdown can wait for them to drain.
+type GracefulServer struct {
+	Server   *http.Server  // Underlying HTTP server being served
+	Listener net.Listener  // Listener in use, possibly inherited
+	wg       sync.WaitGroup // Tracks active connections for GracefulStop
+}
+
+// NewGracefulServer wires up `server` to track connection state via
+// `Server.ConnState`, so that a later call to `GracefulStop` can wait on
+// `GracefulServer.Wait` instead of relying solely on `http.Server.Shutdown`.
+func NewGracefulServer(server *http.Server, ln net.Listener) *GracefulServer {
+	g := &GracefulServer{
+		Server:   server,
+		Listener: ln,
+	}
+
+	prevConnState := server.ConnState
+	server.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			g.wg.Add(1)
+		case http.StateClosed, http.StateHijacked:
+			g.wg.Done()
+		}
+		if prevConnState != nil {
+			prevConnState(conn, state)
+		}
+	}
+
+	return g
+}
+
+// Serve starts serving HTTP requests on the wrapped listener.
+func (g *GracefulServer) Serve() error {
+	return g.Server.Serve(g.Listener)
+}
+
+// Wait blocks until every connection tracked through `Server.ConnState`
+// has closed.
+func (g *GracefulServer) Wait() {
+	g.wg.Wait()
+}
+
+// Listen creates a `net.Listener` for `addr`, preferring - in order - a
+// listener inherited from a parent process across a graceful restart, a
+// listener handed over by systemd socket activation, and finally a plain
+// `net.Listen`.
+func Listen(addr string) (net.Listener, error) {
+	if ln, err := listenerFromEnv(EnvListenerFDs, listenFDStart); err == nil && ln != nil {
+		return ln, nil
+	}
+	if ln, err := listenerFromSystemd(); err == nil && ln != nil {
+		return ln, nil
+	}
+	return net.Listen("tcp", addr)
+}
+
+// listenerFromEnv builds a `net.Listener` from the file descriptor
+// inherited across a `Restart`, as recorded in the `envVar` environment
+// variable.
+func listenerFromEnv(envVar string, fdStart int) (net.Listener, error) {
+	countStr := os.Getenv(envVar)
+	if countStr == "" {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("graceful: invalid %s value %q", envVar, countStr)
+	}
+	f := os.NewFile(uintptr(fdStart), "listener")
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return ln, nil
+}
+
+// listenerFromSystemd implements the sd_listen_fds(3) protocol: file
+// descriptors start at 3 and are only valid for us if LISTEN_PID matches
+// our own PID.
+func listenerFromSystemd() (net.Listener, error) {
+	pidStr := os.Getenv(EnvListenPID)
+	fdsStr := os.Getenv(EnvListenFDs)
+	if pidStr == "" || fdsStr == "" {
+		return nil, nil
+	}
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(fdsStr)
+	if err != nil || fds < 1 {
+		return nil, fmt.Errorf("graceful: invalid %s value %q", EnvListenFDs, fdsStr)
+	}
+	f := os.NewFile(uintptr(listenFDStart), "systemd-listener")
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return ln, nil
+}
+
+// Restart forks/execs the currently running binary, passing `ln`'s file
+// descriptor down via `ExtraFiles` along with the `MSERVER_LISTENER_FDS`
+// environment variable so the child can pick up the listener with
+// `net.FileListener` instead of opening a new one.
+func Restart(ln net.Listener) (*os.Process, error) {
+	f, err := listenerFile(ln)
+	if err != nil {
+		return nil, err
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(execPath, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=1", EnvListenerFDs))
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return cmd.Process, nil
+}
+
+// listenerFile extracts the underlying `*os.File` of a `net.Listener` so
+// it can be passed to a child process via `exec.Cmd.ExtraFiles`.
+func listenerFile(ln net.Listener) (*os.File, error) {
+	type filer interface {
+		File() (*os.File, error)
+	}
+	fl, ok := ln.(filer)
+	if !ok {
+		return nil, fmt.Errorf("graceful: listener %T does not support File()", ln)
+	}
+	return fl.File()
+}
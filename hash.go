@@ -8,11 +8,18 @@ package mserver
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"fmt"
+	"hash"
 	"io"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
 )
 
 // Sha1 function get the SHA1 Hash from a given bytes.Buffer and
@@ -22,62 +29,221 @@ import (
 // That would be 2048 Petabytes
 func Sha1(data *bytes.Buffer) *bytes.Buffer {
 	m := sha1.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Sha256 function get the SHA2-256 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Sha256(data *bytes.Buffer) *bytes.Buffer {
 	m := sha256.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Sha224 function get the SHA2-224 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Sha224(data *bytes.Buffer) *bytes.Buffer {
 	m := sha256.New224()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Sha384 function get the SHA2-384 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Sha384(data *bytes.Buffer) *bytes.Buffer {
 	m := sha512.New384()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Sha512 function get the SHA2-512 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Sha512(data *bytes.Buffer) *bytes.Buffer {
 	m := sha512.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Sha512_224 function get the SHA2-512/224 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Sha512_224(data *bytes.Buffer) *bytes.Buffer {
 	m := sha512.New512_224()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Sha512_256 function get the SHA2-512/256 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Sha512_256(data *bytes.Buffer) *bytes.Buffer {
 	m := sha512.New512_256()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
 }
 
 // Md5 function get the MD5 Hash from a given bytes.Buffer and
 // returns the result also in bytes.Buffer
 func Md5(data *bytes.Buffer) *bytes.Buffer {
 	m := md5.New()
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Sha3_224 function get the SHA3-224 Hash from a given bytes.Buffer and
+// returns the result also in bytes.Buffer
+func Sha3_224(data *bytes.Buffer) *bytes.Buffer {
+	m := sha3.New224()
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Sha3_256 function get the SHA3-256 Hash from a given bytes.Buffer and
+// returns the result also in bytes.Buffer
+func Sha3_256(data *bytes.Buffer) *bytes.Buffer {
+	m := sha3.New256()
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Sha3_384 function get the SHA3-384 Hash from a given bytes.Buffer and
+// returns the result also in bytes.Buffer
+func Sha3_384(data *bytes.Buffer) *bytes.Buffer {
+	m := sha3.New384()
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Sha3_512 function get the SHA3-512 Hash from a given bytes.Buffer and
+// returns the result also in bytes.Buffer
+func Sha3_512(data *bytes.Buffer) *bytes.Buffer {
+	m := sha3.New512()
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Shake128 function absorbs `data` and squeezes `outLen` bytes of
+// variable-length output out of the SHAKE-128 sponge, returning the result
+// in a bytes.Buffer
+func Shake128(data *bytes.Buffer, outLen int) *bytes.Buffer {
+	m := sha3.NewShake128()
 	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
+	out := make([]byte, outLen)
+	m.Read(out)
+	return bytes.NewBuffer(out)
+}
+
+// Shake256 function absorbs `data` and squeezes `outLen` bytes of
+// variable-length output out of the SHAKE-256 sponge, returning the result
+// in a bytes.Buffer
+func Shake256(data *bytes.Buffer, outLen int) *bytes.Buffer {
+	m := sha3.NewShake256()
+	io.Copy(m, data)
+	out := make([]byte, outLen)
+	m.Read(out)
+	return bytes.NewBuffer(out)
+}
+
+// Blake2b_256 function get the BLAKE2b-256 Hash from a given bytes.Buffer
+// and returns the result also in bytes.Buffer
+func Blake2b_256(data *bytes.Buffer) *bytes.Buffer {
+	m, _ := blake2b.New256(nil)
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Blake2b_384 function get the BLAKE2b-384 Hash from a given bytes.Buffer
+// and returns the result also in bytes.Buffer
+func Blake2b_384(data *bytes.Buffer) *bytes.Buffer {
+	m, _ := blake2b.New384(nil)
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Blake2b_512 function get the BLAKE2b-512 Hash from a given bytes.Buffer
+// and returns the result also in bytes.Buffer
+func Blake2b_512(data *bytes.Buffer) *bytes.Buffer {
+	m, _ := blake2b.New512(nil)
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// Blake2s_256 function get the BLAKE2s-256 Hash from a given bytes.Buffer
+// and returns the result also in bytes.Buffer
+func Blake2s_256(data *bytes.Buffer) *bytes.Buffer {
+	m, _ := blake2s.New256(nil)
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+// HMAC function computes the keyed-HMAC of `data` using `key` and the hash
+// algorithm named by `algo` (see `HasherByName` for the supported names),
+// returning the result in a bytes.Buffer. It returns an error if `algo` is
+// not recognized.
+func HMAC(algo string, key, data *bytes.Buffer) (*bytes.Buffer, error) {
+	hasher, ok := hasherRegistry[algo]
+	if !ok {
+		return nil, fmt.Errorf("mserver: unknown hash algorithm %q", algo)
+	}
+	m := hmac.New(hasher.new, key.Bytes())
+	sum, err := HashStream(m, data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(sum), nil
+}
+
+// Hasher is implemented by every hash algorithm known to `HasherByName`, so
+// callers can pick one by name at runtime instead of calling a specific
+// package-level function directly.
+type Hasher interface {
+	// Sum hashes data and returns the digest in a bytes.Buffer
+	Sum(data *bytes.Buffer) *bytes.Buffer
+	// Name returns the algorithm name as accepted by HasherByName
+	Name() string
+}
+
+// namedHasher is the Hasher implementation backing the HasherByName
+// registry; `new` also doubles as the `func() hash.Hash` required by
+// `crypto/hmac.New`.
+type namedHasher struct {
+	name string
+	new  func() hash.Hash
+}
+
+func (h namedHasher) Sum(data *bytes.Buffer) *bytes.Buffer {
+	m := h.new()
+	sum, _ := HashStream(m, data)
+	return bytes.NewBuffer(sum)
+}
+
+func (h namedHasher) Name() string {
+	return h.name
+}
+
+// hasherRegistry lists every algorithm name accepted by HasherByName and HMAC
+var hasherRegistry = map[string]namedHasher{
+	"md5":         {"md5", md5.New},
+	"sha1":        {"sha1", sha1.New},
+	"sha224":      {"sha224", sha256.New224},
+	"sha256":      {"sha256", sha256.New},
+	"sha384":      {"sha384", sha512.New384},
+	"sha512":      {"sha512", sha512.New},
+	"sha3-224":    {"sha3-224", sha3.New224},
+	"sha3-256":    {"sha3-256", sha3.New256},
+	"sha3-384":    {"sha3-384", sha3.New384},
+	"sha3-512":    {"sha3-512", sha3.New512},
+	"blake2b-256": {"blake2b-256", func() hash.Hash { m, _ := blake2b.New256(nil); return m }},
+	"blake2b-384": {"blake2b-384", func() hash.Hash { m, _ := blake2b.New384(nil); return m }},
+	"blake2b-512": {"blake2b-512", func() hash.Hash { m, _ := blake2b.New512(nil); return m }},
+	"blake2s-256": {"blake2s-256", func() hash.Hash { m, _ := blake2s.New256(nil); return m }},
+}
+
+// HasherByName looks up a Hasher by algorithm name, as used by HMAC. See
+// `hasherRegistry` for the list of supported names.
+func HasherByName(algo string) (Hasher, error) {
+	h, ok := hasherRegistry[algo]
+	if !ok {
+		return nil, fmt.Errorf("mserver: unknown hash algorithm %q", algo)
+	}
+	return h, nil
 }
@@ -0,0 +1,83 @@
+// Copyright 2018 @boseji <salearj@hotmail.com> All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file is specially dedicated to the streaming Hash API, for Hashing
+// directly off an `io.Reader`/`io.Writer` instead of materializing the
+// whole message in a `bytes.Buffer` first
+
+package mserver
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// HashStream copies all of r into h via io.Copy and returns the resulting
+// digest, surfacing any error encountered while reading r.
+func HashStream(h hash.Hash, r io.Reader) ([]byte, error) {
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// NewHashWriter returns an io.Writer that can be teed into while a message
+// is hashed incrementally with the algorithm named by `algo` (see
+// `HasherByName` for the supported names), and a finalizer function that
+// returns the digest once every write is done.
+func NewHashWriter(algo string) (io.Writer, func() []byte, error) {
+	hasher, ok := hasherRegistry[algo]
+	if !ok {
+		return nil, nil, fmt.Errorf("mserver: unknown hash algorithm %q", algo)
+	}
+	m := hasher.new()
+	return m, func() []byte { return m.Sum(nil) }, nil
+}
+
+// TeeHasher wraps r so that a single pass over the data computes the
+// digest of every algorithm named in algos simultaneously, via
+// `io.MultiWriter` over one `hash.Hash` per algorithm. Unknown algorithm
+// names are silently skipped. It returns the wrapped Reader to read the
+// data through, and a finalizer that returns a map of algorithm name to
+// digest.
+func TeeHasher(r io.Reader, algos ...string) (io.Reader, func() map[string][]byte) {
+	hashers := make(map[string]hash.Hash, len(algos))
+	writers := make([]io.Writer, 0, len(algos))
+	for _, algo := range algos {
+		hasher, ok := hasherRegistry[algo]
+		if !ok {
+			continue
+		}
+		m := hasher.new()
+		hashers[algo] = m
+		writers = append(writers, m)
+	}
+
+	tr := io.TeeReader(r, io.MultiWriter(writers...))
+
+	return tr, func() map[string][]byte {
+		sums := make(map[string][]byte, len(hashers))
+		for algo, m := range hashers {
+			sums[algo] = m.Sum(nil)
+		}
+		return sums
+	}
+}
+
+// HashBuffer hashes b with the algorithm named by algo (see HasherByName)
+// and returns the digest, surfacing the io.Copy error that the
+// bytes.Buffer-based helpers in hash.go discard silently.
+func HashBuffer(algo string, b *bytes.Buffer) (*bytes.Buffer, error) {
+	hasher, ok := hasherRegistry[algo]
+	if !ok {
+		return nil, fmt.Errorf("mserver: unknown hash algorithm %q", algo)
+	}
+	sum, err := HashStream(hasher.new(), b)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(sum), nil
+}
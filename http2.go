@@ -0,0 +1,73 @@
+// Copyright 2018 @boseji <salearj@hotmail.com> All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file is specially dedicated to HTTP/2, h2c and the per-Server
+// timeout/limits configuration that `StartServer` otherwise leaves at the
+// `net/http` zero values
+
+package mserver
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Config carries the `http.Server` tuning knobs `StartServer` leaves at
+// their zero value - which means no read/write/idle timeouts and no
+// `MaxHeaderBytes`, leaving a plain `StartServer` exposed to slow-loris -
+// plus the switch to enable HTTP/2 cleartext (h2c).
+type Config struct {
+	ReadTimeout       time.Duration // passed through to http.Server.ReadTimeout
+	ReadHeaderTimeout time.Duration // passed through to http.Server.ReadHeaderTimeout
+	WriteTimeout      time.Duration // passed through to http.Server.WriteTimeout
+	IdleTimeout       time.Duration // passed through to http.Server.IdleTimeout
+	MaxHeaderBytes    int           // passed through to http.Server.MaxHeaderBytes
+	EnableH2C         bool          // wrap Handler with h2c.NewHandler for HTTP/2 prior knowledge over cleartext
+}
+
+// StartServerWithConfig creates a server(`http.Server`) using the provided
+// `http.Handler` - which, unlike `StartServer`'s `*http.ServeMux`, can also
+// be a `chi`/`gorilla/mux` router or any other `http.Handler` - setting it
+// up with `addr` as the Server address and applying `cfg`'s timeouts and
+// `MaxHeaderBytes`.
+//
+// When `cfg.EnableH2C` is true, `mux` is wrapped with `h2c.NewHandler` so
+// clients can upgrade to HTTP/2 without TLS; StartServerWithConfig always
+// serves cleartext, so this is the only way it offers HTTP/2. TLS Servers
+// started via `StartTLSServer`/`StartAutocertServer` instead get HTTP/2 the
+// ordinary way, via `http2.ConfigureServer`.
+//
+// The `timeout` parameter keeps the same meaning as in `StartServer`: the
+// wait time before the web server is force-terminated during a shutdown.
+//
+func (p *Mserver) StartServerWithConfig(addr string, mux http.Handler, cfg Config, timeout time.Duration) {
+
+	handler := mux
+	if cfg.EnableH2C {
+		handler = h2c.NewHandler(mux, &http2.Server{})
+	}
+
+	if !p.setupServer(addr, handler, timeout) {
+		return
+	}
+
+	p.Server.ReadTimeout = cfg.ReadTimeout
+	p.Server.ReadHeaderTimeout = cfg.ReadHeaderTimeout
+	p.Server.WriteTimeout = cfg.WriteTimeout
+	p.Server.IdleTimeout = cfg.IdleTimeout
+	p.Server.MaxHeaderBytes = cfg.MaxHeaderBytes
+
+	// StartServerWithConfig always serves cleartext; HTTP/2 only applies
+	// here via h2c (above). For HTTP/2 over TLS, use
+	// StartTLSServer/StartAutocertServer, which call http2.ConfigureServer
+	// themselves.
+
+	log.Printf(" Starting Configured Server at %s", addr)
+
+	go p.startGoServerInternal()
+}
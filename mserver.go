@@ -8,23 +8,26 @@
 //
 // - Internal Errors of the web server
 //
+// It also supports a Graceful Restart, triggered by SIGHUP or a call to
+// `Mserver.GracefulRestart`, that hands the listening socket down to a
+// freshly exec'd copy of the binary without dropping in-flight connections.
+// See the `boseji/mserver/graceful` sub-package for the restart/socket
+// activation machinery.
+//
 package mserver
 
 import (
-	"bytes"
 	"context"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
 	"errors"
-	"fmt"
-	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
+
+	"boseji/mserver/graceful"
 )
 
 // Mserver or Managed Server is a derivation of the standard HTTP server
@@ -39,12 +42,25 @@ import (
 //
 // 3. Error occurs in Normal Server operation
 //
+// It also Graceful Restarts, handing off the listening socket to a newly
+// exec'd copy of the binary, on a 4th Event:
+//
+// 4. SIGHUP is send to program, or `GracefulRestart` is called directly
+//
 type Mserver struct {
-	Server          *http.Server   // Instance of the Server
-	stop            chan os.Signal // Signal Receiver for SIGINT and SIGKILL
-	started         bool           // Indicates if the Server was started or not (default = false)
-	ShutdownTimeout time.Duration  // Timeout before a force shutdown is called
-	Error           error          // Last error in operations of the Server
+	Server          *http.Server             // Instance of the Server
+	Listener        net.Listener             // Listener backing the Server, possibly inherited across a restart
+	RedirectToHTTPS bool                     // Controls the HTTP fallback handler used by StartAutocertServer
+	stop            chan os.Signal           // Signal Receiver for SIGINT and SIGKILL
+	restart         chan os.Signal           // Signal Receiver for SIGHUP, triggers a Graceful Restart
+	started         bool                     // Indicates if the Server was started or not (default = false)
+	ShutdownTimeout time.Duration            // Timeout before a force shutdown is called
+	Error           error                    // Last error in operations of the Server
+	gs              *graceful.GracefulServer // Tracks in-flight connections via Server.ConnState
+	tlsEnabled      bool                     // Set by StartTLSServer/StartAutocertServer to Serve over TLS
+	certFile        string                   // Certificate file used by StartTLSServer
+	keyFile         string                   // Key file used by StartTLSServer
+	altServer       *http.Server             // Secondary Server, used by StartAutocertServer for the :80 challenge/redirect handler
 }
 
 // Server not started Error code
@@ -79,6 +95,30 @@ func (p *Mserver) stopServerInternal() error {
 		return p.Error
 	}
 
+	// Stop delivering SIGHUP and close the Restart Source, ending the
+	// `waitForRestart` loop
+	signal.Stop(p.restart)
+	close(p.restart)
+
+	// Shut down the secondary :80 challenge/redirect Server started by
+	// StartAutocertServer, if any, within the same timeout
+	if p.altServer != nil {
+		p.altServer.Shutdown(ctx)
+	}
+
+	// Belt and braces: make sure every connection tracked by `p.gs` through
+	// `Server.ConnState` has actually closed, bounded by the same timeout
+	// used for the Shutdown above.
+	done := make(chan struct{})
+	go func() {
+		p.gs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
 	return p.Error
 }
 
@@ -101,7 +141,11 @@ func (p *Mserver) startGoServerInternal() {
 	}
 	log.Println(" Server Started ...")
 	p.started = true
-	p.Error = p.Server.ListenAndServe()
+	if p.tlsEnabled {
+		p.Error = p.Server.ServeTLS(p.Listener, p.certFile, p.keyFile)
+	} else {
+		p.Error = p.gs.Serve()
+	}
 	log.Println(" Server Stopping ...")
 	if p.Error != nil {
 		p.stopServerInternal()
@@ -132,31 +176,107 @@ func (p *Mserver) StartDefaultServer(addr string, timeout time.Duration) {
 //
 func (p *Mserver) StartServer(addr string, mux *http.ServeMux, timeout time.Duration) {
 
+	if !p.setupServer(addr, mux, timeout) {
+		return
+	}
+
+	// Message
+	log.Printf(" Starting Default Server at %s", addr)
+
+	// Run the Server
+	go p.startGoServerInternal()
+}
+
+// setupServer validates the common parameters shared by `StartServer`,
+// `StartTLSServer` and `StartAutocertServer`, wires up the Interrupt/Restart
+// signal sources, obtains the Listener and builds the `http.Server`.
+//
+// It returns false when `addr`, `timeout` or `mux` is missing (leaving
+// `p.Error` untouched) or when the Listener cannot be obtained (setting
+// `p.Error`). On success it returns true without starting the Server, so
+// callers can still adjust `p.Server` - e.g. set `TLSConfig` - before
+// kicking off `startGoServerInternal` themselves.
+func (p *Mserver) setupServer(addr string, mux http.Handler, timeout time.Duration) bool {
+
 	// Parameter Errors
 	if len(addr) == 0 || timeout == (0*time.Second) || mux == nil {
-		return
+		return false
 	}
 
 	// Create the Interrupt Source
 	p.stop = make(chan os.Signal)
 	signal.Notify(p.stop, os.Kill, os.Interrupt)
 
+	// Create the Restart Source, SIGHUP triggers a Graceful Restart instead
+	// of a shutdown
+	p.restart = make(chan os.Signal, 1)
+	signal.Notify(p.restart, syscall.SIGHUP)
+	go p.waitForRestart()
+
 	// Assign the Wait Timeout during Shutdown
 	p.ShutdownTimeout = timeout
 
+	// Obtain the Listener, inheriting one handed down across a Graceful
+	// Restart or via systemd socket activation if available, so the
+	// address keeps being served on the same socket
+	ln, err := graceful.Listen(addr)
+	if err != nil {
+		p.Error = err
+		return false
+	}
+	p.Listener = ln
+
 	// Create a Callable Server for Later
 	p.Server = &http.Server{
 		Addr:    addr,
 		Handler: mux,
 	}
 
+	// Wrap the Server in a GracefulServer so it tracks in-flight
+	// connections through `Server.ConnState`, letting GracefulStop wait on
+	// them instead of relying solely on `http.Server.Shutdown`
+	p.gs = graceful.NewGracefulServer(p.Server, p.Listener)
+
 	p.Error = nil
 
-	// Message
-	log.Printf(" Starting Default Server at %s", addr)
+	return true
+}
 
-	// Run the Server
-	go p.startGoServerInternal()
+// waitForRestart blocks on the `Mserver.restart` channel and triggers a
+// `GracefulRestart` every time a SIGHUP arrives, until the channel is
+// closed during shutdown.
+func (p *Mserver) waitForRestart() {
+	for range p.restart {
+		if err := p.GracefulRestart(); err != nil {
+			log.Printf(" Graceful Restart failed: %v", err)
+		}
+	}
+}
+
+// GracefulRestart performs a zero-downtime restart of the Server: the
+// currently running binary is re-exec'd with the listening socket handed
+// down via `graceful.Restart`, and as soon as the child process has been
+// started this process stops accepting new connections and waits up to
+// `Mserver.ShutdownTimeout` ("hammer time") for in-flight requests to
+// finish. It does not wait for the child to begin serving, and it returns
+// rather than exiting the process itself.
+func (p *Mserver) GracefulRestart() error {
+
+	if !p.started {
+		return ErrServerNotStarted
+	}
+
+	if p.Listener == nil {
+		return errors.New("Mserver: No Listener available for Restart")
+	}
+
+	log.Printf(" Restarting Server at %s ...", p.Server.Addr)
+
+	if _, p.Error = graceful.Restart(p.Listener); p.Error != nil {
+		return p.Error
+	}
+
+	return p.stopServerInternal()
 }
 
 // GracefulStop provides a way to stop the server properly with help of
@@ -211,75 +331,3 @@ func NewMserver(addr string, timeout time.Duration) *Mserver {
 	m.StartDefaultServer(addr, timeout)
 	return m
 }
-
-// Sha1 function get the SHA1 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-// As per the FIPS 180-4 :  When a message of any length less than 2^64 bits
-//  We need to use SHA-1, SHA-224 and SHA-256
-// That would be 2048 Petabytes
-func Sha1(data *bytes.Buffer) *bytes.Buffer {
-	m := sha1.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Sha256 function get the SHA2-256 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Sha256(data *bytes.Buffer) *bytes.Buffer {
-	m := sha256.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Sha224 function get the SHA2-224 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Sha224(data *bytes.Buffer) *bytes.Buffer {
-	m := sha256.New224()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Sha384 function get the SHA2-384 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Sha384(data *bytes.Buffer) *bytes.Buffer {
-	m := sha512.New384()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Sha512 function get the SHA2-512 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Sha512(data *bytes.Buffer) *bytes.Buffer {
-	m := sha512.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Sha512_224 function get the SHA2-512/224 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Sha512_224(data *bytes.Buffer) *bytes.Buffer {
-	m := sha512.New512_224()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Sha512_256 function get the SHA2-512/256 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Sha512_256(data *bytes.Buffer) *bytes.Buffer {
-	m := sha512.New512_256()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// Md5 function get the MD5 Hash from a given bytes.Buffer and
-// returns the result also in bytes.Buffer
-func Md5(data *bytes.Buffer) *bytes.Buffer {
-	m := md5.New()
-	io.Copy(m, data)
-	return bytes.NewBuffer(m.Sum(nil))
-}
-
-// BufToHexString converts the bytes.Buffer into a Hexadecimal string
-func BufToHexString(data *bytes.Buffer) string {
-	return fmt.Sprintf("%x", data.Bytes())
-}
@@ -0,0 +1,125 @@
+// Copyright 2018 @boseji <salearj@hotmail.com> All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file is specially dedicated to TLS and Let's Encrypt (ACME) support
+
+package mserver
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+)
+
+// StartTLSServer creates a TLS enabled server(`http.Server`) using the
+// provided `http.ServeMux`, serving on `addr` with the certificate/key pair
+// found at `certFile`/`keyFile`.
+//
+// It participates in the same Graceful Shutdown/Restart machinery as
+// `StartServer`: SIGINT/SIGKILL stop the Server, SIGHUP triggers a Graceful
+// Restart, and `timeout` is used the same way as the wait time before a
+// forced shutdown.
+//
+func (p *Mserver) StartTLSServer(addr string, mux *http.ServeMux, certFile, keyFile string, timeout time.Duration) {
+
+	if len(certFile) == 0 || len(keyFile) == 0 {
+		return
+	}
+
+	if !p.setupServer(addr, mux, timeout) {
+		return
+	}
+
+	p.tlsEnabled = true
+	p.certFile = certFile
+	p.keyFile = keyFile
+
+	// Enable HTTP/2 on top of TLS
+	if err := http2.ConfigureServer(p.Server, &http2.Server{}); err != nil {
+		p.Error = err
+		return
+	}
+
+	log.Printf(" Starting TLS Server at %s", addr)
+
+	go p.startGoServerInternal()
+}
+
+// StartAutocertServer creates a TLS enabled server(`http.Server`) that
+// obtains its certificate automatically from Let's Encrypt via ACME, for
+// the given `domains`, caching issued certificates under `cacheDir` and
+// registering with `email`.
+//
+// A second listener is started on `:80` to serve the ACME HTTP-01
+// challenge; requests that are not part of the challenge fall back to a
+// redirect to the HTTPS equivalent when `Mserver.RedirectToHTTPS` is true,
+// or a 404 otherwise. Both listeners share this `Mserver`'s `stop` channel
+// and `ShutdownTimeout`, so SIGINT/SIGHUP cleanly stop both.
+//
+func (p *Mserver) StartAutocertServer(addr string, mux *http.ServeMux, domains []string, cacheDir, email string, timeout time.Duration) {
+
+	if len(domains) == 0 {
+		return
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+
+	if !p.setupServer(addr, mux, timeout) {
+		return
+	}
+
+	p.tlsEnabled = true
+	p.Server.TLSConfig = m.TLSConfig()
+
+	// Enable HTTP/2 on top of TLS
+	if err := http2.ConfigureServer(p.Server, &http2.Server{}); err != nil {
+		p.Error = err
+		return
+	}
+
+	p.startAutocertChallengeServer(m)
+
+	log.Printf(" Starting Autocert Server at %s", addr)
+
+	go p.startGoServerInternal()
+}
+
+// startAutocertChallengeServer starts the secondary `:80` Server used to
+// answer the ACME HTTP-01 challenge on behalf of `m`, falling back to an
+// HTTPS redirect (or a 404) for every other request.
+func (p *Mserver) startAutocertChallengeServer(m *autocert.Manager) {
+
+	fallback := http.HandlerFunc(http.NotFound)
+	if p.RedirectToHTTPS {
+		fallback = http.HandlerFunc(redirectToHTTPSHandler)
+	}
+
+	p.altServer = &http.Server{
+		Addr:    ":80",
+		Handler: m.HTTPHandler(fallback),
+	}
+
+	go func() {
+		if err := p.altServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf(" Autocert Challenge Server Error: %v", err)
+		}
+	}()
+}
+
+// redirectToHTTPSHandler is the fallback Handler used by
+// `startAutocertChallengeServer` when `Mserver.RedirectToHTTPS` is enabled:
+// any request that is not an ACME challenge is redirected to its HTTPS
+// equivalent.
+func redirectToHTTPSHandler(w http.ResponseWriter, r *http.Request) {
+	target := "https://" + r.Host + r.URL.RequestURI()
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}